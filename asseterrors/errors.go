@@ -0,0 +1,47 @@
+// Package asseterrors defines sentinel errors returned by asset cache
+// operations, so callers can use errors.Is instead of matching on message
+// strings.
+package asseterrors
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrAssetNotFound is returned when an asset referenced by an asset
+	// cache operation isn't present where it's expected to be (e.g. its
+	// blob is missing from the downloaded asset map).
+	ErrAssetNotFound = errors.New("asset not found")
+
+	// ErrAssetDigestMismatch is returned when a downloaded asset's sha256
+	// digest doesn't match the digest it was requested with.
+	ErrAssetDigestMismatch = errors.New("asset digest mismatch")
+
+	// ErrAssetCacheSave is returned when an asset cache image fails to be
+	// written to the daemon or registry.
+	ErrAssetCacheSave = errors.New("unable to save asset cache image")
+)
+
+// SaveError wraps a failure encountered while saving an asset cache image,
+// preserving the underlying cause so callers can match on it with
+// errors.As/errors.Is in addition to matching the generic ErrAssetCacheSave
+// sentinel.
+type SaveError struct {
+	Msg   string
+	Cause error
+}
+
+func (e SaveError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Msg, e.Cause)
+}
+
+func (e SaveError) Unwrap() error {
+	return e.Cause
+}
+
+// Is reports whether target is ErrAssetCacheSave, so existing
+// errors.Is(err, ErrAssetCacheSave) checks keep working against a SaveError.
+func (e SaveError) Is(target error) bool {
+	return target == ErrAssetCacheSave
+}
@@ -4,20 +4,31 @@ import (
 	"archive/tar"
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"github.com/buildpacks/imgutil"
+	"github.com/buildpacks/pack/asseterrors"
 	"github.com/buildpacks/pack/internal/blob"
 	"github.com/buildpacks/pack/internal/dist"
 	"github.com/buildpacks/pack/pkg/archive"
+	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
 	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
 	"io"
 	"io/ioutil"
 	"os"
 	"path"
 	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
 )
 
 const AssetLayersLabel = "io.buildpacks.asset.layers"
@@ -31,8 +42,55 @@ type BuildpackTOML struct {
 }
 
 type CreateAssetCacheOptions struct {
-	ImageName        string
-	Assets           []dist.Asset
+	ImageName string
+	Assets    []dist.Asset
+	// Publish indicates the asset cache should be pushed directly to the
+	// remote registry named by ImageName, rather than loaded into the
+	// daemon. When the assets advertise more than one stack, the cache is
+	// published as an OCI image index with one manifest per stack.
+	Publish bool
+	// CacheDir overrides where downloaded assets are cached on disk,
+	// keyed by sha256. Defaults to $XDG_CACHE_HOME/pack/assets.
+	CacheDir string
+	// NoCache disables the on-disk asset blob cache, forcing every asset
+	// to be re-downloaded.
+	NoCache bool
+	// Concurrency bounds how many assets are downloaded at once. Defaults
+	// to runtime.NumCPU() when <= 0.
+	Concurrency int
+	// Signer, when set, signs the published asset cache image with a
+	// detached, cosign-compatible signature over an attestation of the
+	// assets it contains. Only honored when Publish is set.
+	Signer Signer
+}
+
+// CreateAssetCacheOption configures a single call to Client.CreateAssetCache,
+// for options better expressed as functional options than as fields callers
+// must fill in on every CreateAssetCacheOptions literal.
+type CreateAssetCacheOption func(*CreateAssetCacheOptions)
+
+// WithAssetBlobCache overrides where downloaded assets are cached on disk.
+// Equivalent to setting CreateAssetCacheOptions.CacheDir directly.
+func WithAssetBlobCache(dir string) CreateAssetCacheOption {
+	return func(o *CreateAssetCacheOptions) {
+		o.CacheDir = dir
+	}
+}
+
+// WithAssetDownloadConcurrency bounds how many assets are downloaded at
+// once. Equivalent to setting CreateAssetCacheOptions.Concurrency directly.
+func WithAssetDownloadConcurrency(concurrency int) CreateAssetCacheOption {
+	return func(o *CreateAssetCacheOptions) {
+		o.Concurrency = concurrency
+	}
+}
+
+// WithAssetCacheSigner signs the published asset cache with signer.
+// Equivalent to setting CreateAssetCacheOptions.Signer directly.
+func WithAssetCacheSigner(signer Signer) CreateAssetCacheOption {
+	return func(o *CreateAssetCacheOptions) {
+		o.Signer = signer
+	}
 }
 
 type AssetCacheImage struct {
@@ -49,89 +107,295 @@ func NewAssetCacheImage(img imgutil.Image, assetMap map[string]blob.Blob, assets
 	}
 }
 
-func (c *Client) CreateAssetCache(ctx context.Context, opts CreateAssetCacheOptions) error {
+func (c *Client) CreateAssetCache(ctx context.Context, opts CreateAssetCacheOptions, options ...CreateAssetCacheOption) error {
+	for _, option := range options {
+		option(&opts)
+	}
+
 	validOpts, err := validateConfig(opts)
 	if err != nil {
 		return err
 	}
 
-	// TODO -Dan- add support for remote image creation here
+	assetMap, err := c.downloadAssets(ctx, validOpts)
+	if err != nil {
+		return errors.Wrap(err, "downloading assets")
+	}
+
+	if validOpts.Publish {
+		return publishAssetCache(ctx, validOpts, assetMap)
+	}
+
 	img, err := c.imageFactory.NewImage(validOpts.ImageName, true)
 	if err != nil {
 		return fmt.Errorf("unable to create asset cache image: %q", err)
 	}
 
-	assetMap, err := c.downloadAssets(opts.Assets)
+	assetCacheImage := NewAssetCacheImage(img, assetMap, validOpts.Assets)
+	return assetCacheImage.Save()
+}
+
+// publishAssetCache builds one image per stack advertised by the provided
+// assets and pushes them directly to the registry referenced by
+// opts.ImageName. When more than one stack is present, the per-stack
+// images are assembled into a single OCI image index so that one tag can
+// serve builders running on heterogeneous stacks. ref itself is only
+// written once, as the very last step, so a consumer pulling it mid-publish
+// (or a crash partway through) never sees anything other than the
+// previous complete artifact or the new one - never a single stack's
+// image masquerading as the full multi-stack tag.
+func publishAssetCache(ctx context.Context, opts CreateAssetCacheOptions, assetMap map[string]blob.Blob) error {
+	ref, err := name.ParseReference(opts.ImageName, name.WeakValidation)
 	if err != nil {
-		panic(err)
+		return fmt.Errorf("unable to parse asset cache image name: %q", err)
 	}
 
-	assetCacheImage := NewAssetCacheImage(img, assetMap, opts.Assets)
-	return assetCacheImage.Save()
+	remoteOpts := []remote.Option{
+		remote.WithAuthFromKeychain(authn.DefaultKeychain),
+		remote.WithContext(ctx),
+	}
+
+	assetsByStack := groupAssetsByStack(opts.Assets)
+	if len(assetsByStack) == 0 {
+		return errors.New("no assets to publish")
+	}
+
+	var adds []mutate.IndexAddendum
+	for _, stack := range sortedStackKeys(assetsByStack) {
+		img, err := buildAssetCacheImage(assetsByStack[stack], assetMap)
+		if err != nil {
+			return errors.Wrapf(err, "building asset cache image for stack %q", stack)
+		}
+
+		adds = append(adds, mutate.IndexAddendum{
+			Add:        img,
+			Descriptor: v1.Descriptor{Platform: stackPlatform(stack)},
+		})
+	}
+
+	var digest func() (v1.Hash, error)
+	switch {
+	case len(adds) == 1:
+		img := adds[0].Add
+		if err := remote.Write(ref, img, remoteOpts...); err != nil {
+			return asseterrors.SaveError{Msg: "publishing asset cache image", Cause: err}
+		}
+		digest = img.Digest
+	default:
+		// remote.WriteIndex pushes every manifest and blob referenced by
+		// idx that isn't already on the registry, so there's no need to
+		// separately push each child image first - doing so would also
+		// mean tagging ref at an intermediate, incomplete state.
+		idx := mutate.AppendManifests(empty.Index, adds...)
+		if err := remote.WriteIndex(ref, idx, remoteOpts...); err != nil {
+			return asseterrors.SaveError{Msg: "publishing asset cache index", Cause: err}
+		}
+		digest = idx.Digest
+	}
+
+	if opts.Signer == nil {
+		return nil
+	}
+
+	publishedDigest, err := digest()
+	if err != nil {
+		return errors.Wrap(err, "computing published asset cache digest")
+	}
+
+	return signAssetCache(ctx, ref, publishedDigest, opts.Assets, opts.Signer)
+}
+
+// anyStack is the bucket groupAssetsByStack uses for a set of assets in
+// which none declares a stack at all, so the resulting image isn't tied
+// to any particular one.
+const anyStack = ""
+
+// groupAssetsByStack buckets assets by the stacks they declare. An asset
+// that declares no stacks of its own is assumed to apply to every stack
+// declared by its siblings (or, if none of them declare one either, to
+// the single anyStack bucket), rather than being silently dropped from
+// the published cache.
+func groupAssetsByStack(assets []dist.Asset) map[string][]dist.Asset {
+	stacks := map[string]bool{}
+	for _, asset := range assets {
+		for _, stack := range asset.Stacks {
+			stacks[stack] = true
+		}
+	}
+
+	result := map[string][]dist.Asset{}
+	for _, asset := range assets {
+		switch {
+		case len(asset.Stacks) > 0:
+			for _, stack := range asset.Stacks {
+				result[stack] = append(result[stack], asset)
+			}
+		case len(stacks) > 0:
+			for stack := range stacks {
+				result[stack] = append(result[stack], asset)
+			}
+		default:
+			result[anyStack] = append(result[anyStack], asset)
+		}
+	}
+	return result
+}
+
+func sortedStackKeys(assetsByStack map[string][]dist.Asset) []string {
+	stacks := make([]string, 0, len(assetsByStack))
+	for stack := range assetsByStack {
+		stacks = append(stacks, stack)
+	}
+	sort.Strings(stacks)
+	return stacks
+}
+
+// stackPlatform maps a buildpack stack id to the platform recorded on its
+// index manifest. Stacks don't currently declare an architecture, so amd64
+// is assumed until that's surfaced in dist.Asset.
+func stackPlatform(stack string) *v1.Platform {
+	return &v1.Platform{
+		OS:           "linux",
+		Architecture: "amd64",
+		OSVersion:    stack,
+	}
+}
+
+// buildAssetCacheImage constructs a ggcr v1.Image with one layer per asset,
+// each tarred up per toDistTar, labeled with AssetLayersLabel. This mirrors
+// the layer-per-asset layout (*AssetCacheImage).Save() produces for the
+// daemon path, so a consumer can mount a single asset's layer without
+// pulling down every other asset sharing its stack.
+func buildAssetCacheImage(assets []dist.Asset, assetMap map[string]blob.Blob) (v1.Image, error) {
+	sortedAssets := make([]dist.Asset, len(assets))
+	copy(sortedAssets, assets)
+	sort.Slice(sortedAssets, func(i, j int) bool {
+		return sortedAssets[i].Sha256 < sortedAssets[j].Sha256
+	})
+
+	var layers []v1.Layer
+	assetLabel := AssetMetadata{}
+	for _, asset := range sortedAssets {
+		assetBlob, ok := assetMap[asset.Sha256]
+		if !ok {
+			return nil, fmt.Errorf("%w: associated asset blob does not exist for %q", asseterrors.ErrAssetNotFound, asset.Sha256)
+		}
+
+		buf := bytes.NewBuffer(nil)
+		tw := tar.NewWriter(buf)
+		if err := toDistTar(tw, asset.Sha256, assetBlob); err != nil {
+			return nil, err
+		}
+		if err := tw.Close(); err != nil {
+			return nil, err
+		}
+
+		layer, err := tarball.LayerFromReader(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			return nil, err
+		}
+
+		diffID, err := layer.DiffID()
+		if err != nil {
+			return nil, err
+		}
+		asset.LayerDiffID = diffID.String()
+		assetLabel[asset.Sha256] = asset
+
+		layers = append(layers, layer)
+	}
+
+	img, err := mutate.AppendLayers(empty.Image, layers...)
+	if err != nil {
+		return nil, err
+	}
+
+	assetLabelBuf := bytes.NewBuffer(nil)
+	if err := json.NewEncoder(assetLabelBuf).Encode(assetLabel); err != nil {
+		return nil, err
+	}
+
+	cfg, err := img.ConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	cfg = cfg.DeepCopy()
+	if cfg.Config.Labels == nil {
+		cfg.Config.Labels = map[string]string{}
+	}
+	cfg.Config.Labels[AssetLayersLabel] = assetLabelBuf.String()
+
+	return mutate.ConfigFile(img, cfg)
 }
 
 func (a *AssetCacheImage) Save() error {
 	tmpDir, err := ioutil.TempDir("", "create-asset-scratch")
 	if err != nil {
-		panic(err)
+		return errors.Wrap(err, "creating asset cache scratch dir")
 	}
 	defer os.RemoveAll(tmpDir)
 
+	sortedAssets := make([]dist.Asset, len(a.Assets))
+	copy(sortedAssets, a.Assets)
+	sort.Slice(sortedAssets, func(i, j int) bool {
+		return sortedAssets[i].Sha256 < sortedAssets[j].Sha256
+	})
+
 	var dstTar *os.File
 	assetLabel := AssetMetadata{}
-	for _, asset := range a.Assets {
+	for _, asset := range sortedAssets {
 		blob, ok := a.AssetMap[asset.Sha256]
 		if !ok {
-			panic("associated asset blob does not exist")
+			return fmt.Errorf("%w: associated asset blob does not exist for %q", asseterrors.ErrAssetNotFound, asset.Sha256)
 		}
 		// check permissions bits here....
 		{
 			// TODO -DAN- audit permission bits here here
 			layerPath := filepath.Join(tmpDir, asset.Sha256)
 			dstTar, err = os.OpenFile(layerPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, os.ModePerm)
-			defer dstTar.Close()
 			if err != nil {
-				panic(err)
+				return errors.Wrapf(err, "opening layer scratch file for %q", asset.Sha256)
 			}
+			defer dstTar.Close()
 
 			// TODO -DAN- use ggcr utilities here to standardize
 			hashAlgo := "sha256"
 			hash, err := v1.Hasher("sha256")
 			if err != nil {
-				panic(err)
+				return errors.Wrap(err, "initializing layer hasher")
 			}
 
 			w := io.MultiWriter(dstTar, hash)
 			tw := tar.NewWriter(w)
 			if err = toDistTar(tw, asset.Sha256, blob); err != nil {
-				panic(err)
+				return errors.Wrapf(err, "writing layer tar for %q", asset.Sha256)
 			}
 			if err = a.img.AddLayer(layerPath); err != nil {
-				panic(err)
+				return asseterrors.SaveError{Msg: fmt.Sprintf("adding layer for %q", asset.Sha256), Cause: err}
 			}
 			if err = dstTar.Close(); err != nil {
-				panic(err)
+				return errors.Wrapf(err, "closing layer scratch file for %q", asset.Sha256)
 			}
 
-			asset.LayerDiffID = fmt.Sprintf("%s:%x",hashAlgo,  hash.Sum(nil))
+			asset.LayerDiffID = fmt.Sprintf("%s:%x", hashAlgo, hash.Sum(nil))
 			assetLabel[asset.Sha256] = asset
-
 		}
 	}
 
 	assetLabelBuf := bytes.NewBuffer(nil)
-	err = json.NewEncoder(assetLabelBuf).Encode(assetLabel)
-	if err != nil {
-		panic(err)
+	if err := json.NewEncoder(assetLabelBuf).Encode(assetLabel); err != nil {
+		return errors.Wrap(err, "encoding asset cache metadata label")
 	}
 
+	if err := a.img.SetLabel(AssetLayersLabel, assetLabelBuf.String()); err != nil {
+		return asseterrors.SaveError{Msg: "setting asset layers label", Cause: err}
+	}
 
-	err = a.img.SetLabel(AssetLayersLabel, assetLabelBuf.String())
-	if err != nil {
-		panic(err)
+	if err := a.img.Save(); err != nil {
+		return asseterrors.SaveError{Msg: "saving asset cache image", Cause: err}
 	}
 
-	return a.img.Save()
+	return nil
 }
 
 func toDistTar(tw archive.TarWriter, blobSha string, blob dist.Blob) error {
@@ -142,6 +406,10 @@ func toDistTar(tw archive.TarWriter, blobSha string, blob dist.Blob) error {
 		Name:     path.Join("cnb"),
 		Mode:     0755,
 		ModTime:  ts,
+		Uid:      0,
+		Gid:      0,
+		Uname:    "",
+		Gname:    "",
 	}); err != nil {
 		return errors.Wrapf(err, "writing buildpack id dir header")
 	}
@@ -151,6 +419,10 @@ func toDistTar(tw archive.TarWriter, blobSha string, blob dist.Blob) error {
 		Name:     path.Join("cnb", "assets"),
 		Mode:     0755,
 		ModTime:  ts,
+		Uid:      0,
+		Gid:      0,
+		Uname:    "",
+		Gname:    "",
 	}); err != nil {
 		return errors.Wrapf(err, "writing buildpack version dir header")
 	}
@@ -158,13 +430,12 @@ func toDistTar(tw archive.TarWriter, blobSha string, blob dist.Blob) error {
 	buf := bytes.NewBuffer(nil)
 	rc, err := blob.Open()
 	if err != nil {
-		panic(err)
+		return errors.Wrap(err, "opening asset blob")
 	}
 	defer rc.Close()
 
-	_, err = io.Copy(buf, rc)
-	if err != nil {
-		panic(err)
+	if _, err := io.Copy(buf, rc); err != nil {
+		return errors.Wrap(err, "reading asset blob")
 	}
 
 	if err := tw.WriteHeader(&tar.Header{
@@ -173,6 +444,10 @@ func toDistTar(tw archive.TarWriter, blobSha string, blob dist.Blob) error {
 		Mode:     0755,
 		Size:     int64(buf.Len()),
 		ModTime:  ts,
+		Uid:      0,
+		Gid:      0,
+		Uname:    "",
+		Gname:    "",
 	}); err != nil {
 		return errors.Wrapf(err, "writing buildpack version dir header")
 	}
@@ -181,25 +456,144 @@ func toDistTar(tw archive.TarWriter, blobSha string, blob dist.Blob) error {
 	return err
 }
 
-func (c *Client) downloadAssets(assets []dist.Asset) (map[string]blob.Blob, error) {
-	result := make(map[string]blob.Blob)
-	for _, asset := range assets {
-		// TODO -Dan- validate the asset before downloading
-		b, err := c.downloader.Download(context.Background(), asset.URI, blob.RawOption)
-		if err != nil {
-			return map[string]blob.Blob{}, err
+func (c *Client) downloadAssets(ctx context.Context, opts CreateAssetCacheOptions) (map[string]blob.Blob, error) {
+	fetch, err := c.assetFetcher(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = runtime.NumCPU()
+	}
+
+	var (
+		mu     sync.Mutex
+		result = make(map[string]blob.Blob)
+	)
+
+	eg, egCtx := errgroup.WithContext(ctx)
+	sem := make(chan struct{}, concurrency)
+
+	for _, asset := range opts.Assets {
+		asset := asset
+
+		select {
+		case sem <- struct{}{}:
+		case <-egCtx.Done():
+			return nil, eg.Wait()
 		}
-		result[asset.Sha256] = b
+
+		eg.Go(func() error {
+			defer func() { <-sem }()
+
+			c.logger.Debugf("Downloading asset %s (%s)", asset.ID, asset.Sha256)
+			b, size, err := fetch(egCtx, asset)
+			if err != nil {
+				return err
+			}
+			c.logger.Debugf("Finished downloading asset %s (%s): %d bytes", asset.ID, asset.Sha256, size)
+
+			mu.Lock()
+			result[asset.Sha256] = b
+			mu.Unlock()
+			return nil
+		})
+	}
+
+	if err := eg.Wait(); err != nil {
+		return nil, err
 	}
+
 	return result, nil
 }
 
+// assetFetcher returns the function used to resolve a single asset to a
+// blob and its size in bytes, either bypassing the on-disk cache entirely
+// (opts.NoCache) or serving/populating it keyed by the asset's sha256.
+// The --no-cache path skips CachingDownloader entirely, so its digest is
+// verified here instead, folded into the same read pass that determines
+// size so a large asset is never read through twice just for logging.
+func (c *Client) assetFetcher(opts CreateAssetCacheOptions) (func(ctx context.Context, asset dist.Asset) (blob.Blob, int64, error), error) {
+	if opts.NoCache {
+		return func(ctx context.Context, asset dist.Asset) (blob.Blob, int64, error) {
+			b, err := c.downloader.Download(ctx, asset.URI, blob.RawOption)
+			if err != nil {
+				return nil, 0, err
+			}
+			size, err := verifyAssetDigest(asset.URI, asset.Sha256, b)
+			if err != nil {
+				return nil, 0, err
+			}
+			return b, size, nil
+		}, nil
+	}
+
+	cacheDir, err := assetCacheDir(opts.CacheDir)
+	if err != nil {
+		return nil, err
+	}
+	cachingDownloader := blob.NewCachingDownloader(c.downloader, cacheDir)
+
+	return func(ctx context.Context, asset dist.Asset) (blob.Blob, int64, error) {
+		return cachingDownloader.Download(ctx, asset.URI, asset.Sha256)
+	}, nil
+}
+
+// verifyAssetDigest reads b's full contents, hashing them as they're
+// copied, and returns the number of bytes read. It returns an error
+// wrapping blob.ErrDigestMismatch if the resulting sha256 doesn't match
+// expectedSha256.
+func verifyAssetDigest(uri, expectedSha256 string, b blob.Blob) (int64, error) {
+	rc, err := b.Open()
+	if err != nil {
+		return 0, err
+	}
+	defer rc.Close()
+
+	hash := sha256.New()
+	n, err := io.Copy(hash, rc)
+	if err != nil {
+		return 0, err
+	}
+
+	if actual := fmt.Sprintf("%x", hash.Sum(nil)); actual != expectedSha256 {
+		return n, blob.ErrDigestMismatch{URI: uri, Expected: expectedSha256, Actual: actual}
+	}
+	return n, nil
+}
+
+// assetCacheDir returns override, or $XDG_CACHE_HOME/pack/assets (honored
+// by os.UserCacheDir on Linux and its platform equivalents elsewhere),
+// creating it if necessary.
+func assetCacheDir(override string) (string, error) {
+	dir := override
+	if dir == "" {
+		userCacheDir, err := os.UserCacheDir()
+		if err != nil {
+			return "", errors.Wrap(err, "resolving asset cache directory")
+		}
+		dir = filepath.Join(userCacheDir, "pack", "assets")
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", errors.Wrap(err, "creating asset cache directory")
+	}
+	return dir, nil
+}
+
 func validateConfig(cfg CreateAssetCacheOptions) (CreateAssetCacheOptions, error) {
 	tag, err := name.NewTag(cfg.ImageName, name.WeakValidation)
 	if err != nil {
 		return CreateAssetCacheOptions{}, fmt.Errorf("invalid asset cache image name: %q", err)
 	}
 	return CreateAssetCacheOptions{
-		ImageName: tag.String(),
+		ImageName:   tag.String(),
+		Assets:      cfg.Assets,
+		Publish:     cfg.Publish,
+		CacheDir:    cfg.CacheDir,
+		NoCache:     cfg.NoCache,
+		Concurrency: cfg.Concurrency,
+		Signer:      cfg.Signer,
 	}, nil
 }
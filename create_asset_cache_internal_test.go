@@ -0,0 +1,105 @@
+package pack
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/buildpacks/pack/internal/blob"
+	"github.com/buildpacks/pack/internal/dist"
+	h "github.com/buildpacks/pack/testhelpers"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+// newTestBlob writes contents to a scratch file and returns a Blob backed
+// by it, cleaned up when the test completes.
+func newTestBlob(t *testing.T, contents string) blob.Blob {
+	t.Helper()
+	f, err := ioutil.TempFile("", "asset-cache-test-blob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Remove(f.Name()) })
+
+	if _, err := f.WriteString(contents); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return blob.NewBlob(f.Name())
+}
+
+func TestGroupAssetsByStack(t *testing.T) {
+	spec.Run(t, "groupAssetsByStack", testGroupAssetsByStack, spec.Parallel(), spec.Report(report.Terminal{}))
+}
+
+func testGroupAssetsByStack(t *testing.T, when spec.G, it spec.S) {
+	var assert = h.NewAssertionManager(t)
+
+	when("every asset declares a stack", func() {
+		it("buckets assets under each stack they declare", func() {
+			bionic := dist.Asset{ID: "bionic-asset", Stacks: []string{"io.buildpacks.stacks.bionic"}}
+			shared := dist.Asset{ID: "shared-asset", Stacks: []string{"io.buildpacks.stacks.bionic", "io.buildpacks.stacks.focal"}}
+
+			result := groupAssetsByStack([]dist.Asset{bionic, shared})
+			assert.Equal(result["io.buildpacks.stacks.bionic"], []dist.Asset{bionic, shared})
+			assert.Equal(result["io.buildpacks.stacks.focal"], []dist.Asset{shared})
+		})
+	})
+
+	when("an asset declares no stack of its own", func() {
+		it("applies it to every stack its siblings declare, instead of dropping it", func() {
+			bionic := dist.Asset{ID: "bionic-asset", Stacks: []string{"io.buildpacks.stacks.bionic"}}
+			stackless := dist.Asset{ID: "stackless-asset"}
+
+			result := groupAssetsByStack([]dist.Asset{bionic, stackless})
+			assert.Equal(result["io.buildpacks.stacks.bionic"], []dist.Asset{bionic, stackless})
+		})
+	})
+
+	when("no asset declares a stack", func() {
+		it("buckets every asset under anyStack", func() {
+			stackless := dist.Asset{ID: "stackless-asset"}
+
+			result := groupAssetsByStack([]dist.Asset{stackless})
+			assert.Equal(result[anyStack], []dist.Asset{stackless})
+		})
+	})
+}
+
+func TestBuildAssetCacheImage(t *testing.T) {
+	spec.Run(t, "buildAssetCacheImage", testBuildAssetCacheImage, spec.Parallel(), spec.Report(report.Terminal{}))
+}
+
+func testBuildAssetCacheImage(t *testing.T, when spec.G, it spec.S) {
+	var assert = h.NewAssertionManager(t)
+
+	when("building an image for multiple assets", func() {
+		it("gives each asset its own layer with its own diffID", func() {
+			assets := []dist.Asset{
+				{ID: "first-asset", Sha256: "first-sha256"},
+				{ID: "second-asset", Sha256: "second-sha256"},
+			}
+			assetMap := map[string]blob.Blob{
+				"first-sha256":  newTestBlob(t, "first-asset-contents"),
+				"second-sha256": newTestBlob(t, "second-asset-contents"),
+			}
+
+			img, err := buildAssetCacheImage(assets, assetMap)
+			assert.Nil(err)
+
+			layers, err := img.Layers()
+			assert.Nil(err)
+			assert.Equal(len(layers), len(assets))
+
+			firstDiffID, err := layers[0].DiffID()
+			assert.Nil(err)
+			secondDiffID, err := layers[1].DiffID()
+			assert.Nil(err)
+			assert.NotEqual(firstDiffID.String(), secondDiffID.String())
+		})
+	})
+}
@@ -161,6 +161,52 @@ second-asset-blob-contents.
 			})
 		})
 
+		when("building the same cache twice", func() {
+			it("produces byte-identical layer diffIDs", func() {
+				assetBlobPath := filepath.Join(tmpDir, "assetBlob")
+				assert.Succeeds(ioutil.WriteFile(assetBlobPath, []byte(`asset-blob-contents.`), os.ModePerm))
+				assetBlob := blob.NewBlob(assetBlobPath)
+				assetSha256 := "2914c51766f10a15370dba80ae3f0255023f427a96858c29fbeb3c7aea60a97b" // sha256 of "asset-blob-contents."
+
+				assets := []dist.Asset{
+					{
+						ID:     "some-asset",
+						Name:   "Some Asset",
+						Sha256: assetSha256,
+						Stacks: []string{"io.buildpacks.stacks.bionic"},
+						URI:    "https://some-asset-uri",
+					},
+				}
+
+				buildOnce := func(imageName string) string {
+					imgRef, err := name.NewTag(imageName)
+					assert.Nil(err)
+					img := fakes.NewImage(imageName, "somesha256", imgRef)
+
+					mockImageFactory.EXPECT().NewImage(imageName, true).Return(img, nil)
+					mockDownloader.EXPECT().Download(gomock.Any(), "https://some-asset-uri", gomock.Any()).Return(assetBlob, nil)
+
+					assert.Succeeds(client.CreateAssetCache(context.Background(), pack.CreateAssetCacheOptions{
+						ImageName: imageName,
+						Assets:    assets,
+						NoCache:   true,
+					}))
+
+					layersLabel, err := img.Label(pack.AssetLayersLabel)
+					assert.Nil(err)
+
+					var assetMetadata pack.AssetMetadata
+					assert.Succeeds(json.NewDecoder(strings.NewReader(layersLabel)).Decode(&assetMetadata))
+					return assetMetadata[assetSha256].LayerDiffID
+				}
+
+				firstDiffID := buildOnce("first-cache-image")
+				secondDiffID := buildOnce("second-cache-image")
+
+				assert.Equal(firstDiffID, secondDiffID)
+			})
+		})
+
 		when("failure cases", func() {
 			when("invalid image name", func() {
 				it("fails with an error message", func() {
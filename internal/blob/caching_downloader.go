@@ -0,0 +1,121 @@
+package blob
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/buildpacks/pack/asseterrors"
+	"github.com/pkg/errors"
+)
+
+// ErrDigestMismatch is returned by CachingDownloader when a downloaded
+// payload's sha256 digest doesn't match the digest it was requested with.
+// It unwraps to asseterrors.ErrAssetDigestMismatch.
+type ErrDigestMismatch struct {
+	URI      string
+	Expected string
+	Actual   string
+}
+
+func (e ErrDigestMismatch) Error() string {
+	return fmt.Sprintf("downloaded content from %q has digest %q, expected %q", e.URI, e.Actual, e.Expected)
+}
+
+func (e ErrDigestMismatch) Unwrap() error {
+	return asseterrors.ErrAssetDigestMismatch
+}
+
+// CachingDownloader wraps a Downloader with a persistent, content-addressable
+// cache on disk keyed by the expected sha256 of the downloaded payload.
+// Cache hits avoid re-downloading the same asset on every invocation; on a
+// miss, the downloaded content is hashed while it's written to a temp file
+// and only renamed into the cache once the digest is confirmed, so a
+// corrupt or mismatched download never poisons the cache.
+type CachingDownloader struct {
+	downloader Downloader
+	cacheDir   string
+}
+
+// NewCachingDownloader returns a CachingDownloader that stores payloads
+// under cacheDir, sharded by the first two characters of their sha256, e.g.
+// <cacheDir>/ab/ab54...
+func NewCachingDownloader(downloader Downloader, cacheDir string) *CachingDownloader {
+	return &CachingDownloader{
+		downloader: downloader,
+		cacheDir:   cacheDir,
+	}
+}
+
+// Download returns the cached blob for expectedSha256 and its size in
+// bytes if present, otherwise downloads it from uri, verifies its digest,
+// and populates the cache. The size of a cache hit comes from a stat, not
+// a read, and the size of a miss comes from the same pass that hashes it
+// for verification, so a cached asset's content is never read through
+// twice just to report its size.
+func (d *CachingDownloader) Download(ctx context.Context, uri, expectedSha256 string) (Blob, int64, error) {
+	cachePath := d.pathFor(expectedSha256)
+	if info, err := os.Stat(cachePath); err == nil {
+		return NewBlob(cachePath), info.Size(), nil
+	}
+
+	b, err := d.downloader.Download(ctx, uri, RawOption)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	size, err := d.store(uri, expectedSha256, b)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return NewBlob(cachePath), size, nil
+}
+
+func (d *CachingDownloader) store(uri, expectedSha256 string, b Blob) (int64, error) {
+	cachePath := d.pathFor(expectedSha256)
+	if err := os.MkdirAll(filepath.Dir(cachePath), 0755); err != nil {
+		return 0, errors.Wrap(err, "creating asset blob cache dir")
+	}
+
+	tmp, err := ioutil.TempFile(d.cacheDir, "blob-download-*")
+	if err != nil {
+		return 0, errors.Wrap(err, "creating asset blob cache tempfile")
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	rc, err := b.Open()
+	if err != nil {
+		return 0, err
+	}
+	defer rc.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), rc)
+	if err != nil {
+		return 0, err
+	}
+
+	if actual := fmt.Sprintf("%x", hash.Sum(nil)); actual != expectedSha256 {
+		return 0, ErrDigestMismatch{URI: uri, Expected: expectedSha256, Actual: actual}
+	}
+
+	if err := tmp.Close(); err != nil {
+		return 0, err
+	}
+
+	if err := os.Rename(tmp.Name(), cachePath); err != nil {
+		return 0, err
+	}
+
+	return size, nil
+}
+
+func (d *CachingDownloader) pathFor(sha256 string) string {
+	return filepath.Join(d.cacheDir, sha256[:2], sha256)
+}
@@ -0,0 +1,126 @@
+package blob_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/buildpacks/pack/internal/blob"
+	h "github.com/buildpacks/pack/testhelpers"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestCachingDownloader(t *testing.T) {
+	spec.Run(t, "CachingDownloader", testCachingDownloader, spec.Parallel(), spec.Report(report.Terminal{}))
+}
+
+// fakeDownloader serves fixed content for a uri and counts how many times
+// it's been asked to download it, so tests can assert on cache hits.
+type fakeDownloader struct {
+	content map[string][]byte
+	calls   map[string]int
+}
+
+func newFakeDownloader() *fakeDownloader {
+	return &fakeDownloader{content: map[string][]byte{}, calls: map[string]int{}}
+}
+
+func (f *fakeDownloader) Download(ctx context.Context, uri string, opts ...blob.Option) (blob.Blob, error) {
+	f.calls[uri]++
+
+	tmpFile, err := ioutil.TempFile("", "fake-download")
+	if err != nil {
+		return nil, err
+	}
+	defer tmpFile.Close()
+
+	if _, err := tmpFile.Write(f.content[uri]); err != nil {
+		return nil, err
+	}
+
+	return blob.NewBlob(tmpFile.Name()), nil
+}
+
+func testCachingDownloader(t *testing.T, when spec.G, it spec.S) {
+	var (
+		assert   = h.NewAssertionManager(t)
+		tmpDir   string
+		fake     *fakeDownloader
+		cacheDir string
+	)
+
+	it.Before(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "caching-downloader")
+		assert.Nil(err)
+
+		cacheDir = filepath.Join(tmpDir, "cache")
+		fake = newFakeDownloader()
+	})
+
+	it.After(func() {
+		os.RemoveAll(tmpDir)
+	})
+
+	when("the asset isn't cached yet", func() {
+		it("downloads it, verifies its digest, and populates the cache", func() {
+			uri := "https://example.com/asset"
+			fake.content[uri] = []byte("asset-contents")
+			sha256 := "fa13564587b897ded7edb026fc248dfc6cfecce5013d99006cf4835c0b9005d3" // sha256 of "asset-contents"
+
+			downloader := blob.NewCachingDownloader(fake, cacheDir)
+			b, size, err := downloader.Download(context.Background(), uri, sha256)
+			assert.Nil(err)
+			assert.Equal(size, int64(len("asset-contents")))
+
+			rc, err := b.Open()
+			assert.Nil(err)
+			defer rc.Close()
+			content, err := ioutil.ReadAll(rc)
+			assert.Nil(err)
+			assert.Equal(string(content), "asset-contents")
+
+			assert.Equal(fake.calls[uri], 1)
+		})
+	})
+
+	when("the asset is already cached", func() {
+		it("serves it from the cache without re-downloading", func() {
+			uri := "https://example.com/asset"
+			fake.content[uri] = []byte("asset-contents")
+			sha256 := "fa13564587b897ded7edb026fc248dfc6cfecce5013d99006cf4835c0b9005d3"
+
+			downloader := blob.NewCachingDownloader(fake, cacheDir)
+			_, _, err := downloader.Download(context.Background(), uri, sha256)
+			assert.Nil(err)
+
+			_, size, err := downloader.Download(context.Background(), uri, sha256)
+			assert.Nil(err)
+			assert.Equal(size, int64(len("asset-contents")))
+
+			assert.Equal(fake.calls[uri], 1)
+		})
+	})
+
+	when("the downloaded content doesn't match the expected digest", func() {
+		it("returns ErrDigestMismatch and leaves the cache empty", func() {
+			uri := "https://example.com/asset"
+			fake.content[uri] = []byte("asset-contents")
+
+			downloader := blob.NewCachingDownloader(fake, cacheDir)
+			_, _, err := downloader.Download(context.Background(), uri, "not-the-real-digest")
+			assert.ErrorContains(err, "expected \"not-the-real-digest\"")
+
+			entries, err := ioutil.ReadDir(cacheDir)
+			assert.Nil(err)
+			for _, entry := range entries {
+				shard, err := ioutil.ReadDir(filepath.Join(cacheDir, entry.Name()))
+				assert.Nil(err)
+				assert.Equal(len(shard), 0)
+			}
+		})
+	})
+}
@@ -1,6 +1,7 @@
 package commands
 
 import (
+	"github.com/BurntSushi/toml"
 	"github.com/buildpacks/pack"
 	pubcfg "github.com/buildpacks/pack/config"
 	"github.com/buildpacks/pack/internal/config"
@@ -9,15 +10,22 @@ import (
 	"github.com/buildpacks/pack/logging"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
+	"fmt"
+	"path/filepath"
 	"sort"
 )
 
 type CreateAssetCacheFlags struct {
 	BuildpackLocator string
 	PullPolicy       pubcfg.PullPolicy
-	Publish          bool //TODO -Dan- implement
+	Publish          bool
 	Registry         string
 	Policy           string
+	CacheDir         string
+	NoCache          bool
+	Parallel         int
+	Config           string
+	SignWithKey      string
 }
 
 func CreateAssetCache(logger logging.Logger, cfg config.Config, client PackClient) *cobra.Command {
@@ -39,8 +47,7 @@ func CreateAssetCache(logger logging.Logger, cfg config.Config, client PackClien
 			stringPolicy := flags.Policy
 			pullPolicy, err := pubcfg.ParsePullPolicy(stringPolicy)
 			if err != nil {
-				panic(err)
-				//return errors.Wrapf(err, "parsing pull policy %s", flags.Policy)
+				return errors.Wrapf(err, "parsing pull policy %s", flags.Policy)
 			}
 
 			inspectOptions := []pack.InspectBuildpackOptions{}
@@ -76,16 +83,42 @@ func CreateAssetCache(logger logging.Logger, cfg config.Config, client PackClien
 			buildpackInfo, err := tryInspect(client, inspectOptions)
 			switch {
 			case err != nil:
-				panic(err)
+				return errors.Wrapf(err, "inspecting buildpack %s", flags.BuildpackLocator)
 			case buildpackInfo == nil:
-				panic("no image found")
+				return fmt.Errorf("no image found for buildpack %s", flags.BuildpackLocator)
+			}
+
+			assets := getAssets(buildpackInfo)
+			if flags.Config != "" {
+				assetCacheCfg, err := readAssetCacheConfig(flags.Config)
+				if err != nil {
+					return errors.Wrapf(err, "reading asset cache config %s", flags.Config)
+				}
+
+				assets, err = filterAssets(assets, assetCacheCfg)
+				if err != nil {
+					return err
+				}
+			}
+
+			var assetCacheOpts []pack.CreateAssetCacheOption
+			if flags.SignWithKey != "" {
+				signer, err := pack.NewFileKeySigner(flags.SignWithKey)
+				if err != nil {
+					return errors.Wrap(err, "loading asset cache signing key")
+				}
+				assetCacheOpts = append(assetCacheOpts, pack.WithAssetCacheSigner(signer))
 			}
 
 			if err := client.CreateAssetCache(cmd.Context(), pack.CreateAssetCacheOptions{
-				ImageName: args[0],
-				Assets:    getAssets(buildpackInfo),
-			}); err != nil {
-				panic(err)
+				ImageName:   args[0],
+				Assets:      assets,
+				Publish:     flags.Publish,
+				CacheDir:    flags.CacheDir,
+				NoCache:     flags.NoCache,
+				Concurrency: flags.Parallel,
+			}, assetCacheOpts...); err != nil {
+				return errors.Wrap(err, "creating asset cache")
 			}
 
 			return nil
@@ -95,7 +128,12 @@ func CreateAssetCache(logger logging.Logger, cfg config.Config, client PackClien
 	cmd.Flags().StringVarP(&flags.BuildpackLocator, "buildpack", "b", "", "Buildpack Locator")
 	cmd.Flags().StringVar(&flags.Policy, "pull-policy", cfg.PullPolicy, "Pull policy to use. Accepted values are always, never, and if-not-present. The default is always")
 	cmd.Flags().StringVarP(&flags.Registry, "buildpack-registry", "R", cfg.DefaultRegistryName, "Buildpack Registry by name")
-	cmd.Flags().StringVarP(&flags.BuildpackLocator, "config", "c", "", "optional asset-cache.toml to filter assets in the resulting asset cache")
+	cmd.Flags().StringVarP(&flags.Config, "config", "c", "", "optional asset-cache.toml to filter assets in the resulting asset cache")
+	cmd.Flags().BoolVar(&flags.Publish, "publish", false, "Publish the asset cache directly to the registry instead of the daemon")
+	cmd.Flags().StringVar(&flags.CacheDir, "cache-dir", "", "Directory to store downloaded assets in, to avoid re-downloading them on subsequent runs. Defaults to $XDG_CACHE_HOME/pack/assets")
+	cmd.Flags().BoolVar(&flags.NoCache, "no-cache", false, "Skip the on-disk asset cache and re-download every asset")
+	cmd.Flags().IntVar(&flags.Parallel, "parallel", 0, "Maximum number of assets to download concurrently. Defaults to the number of CPUs")
+	cmd.Flags().StringVar(&flags.SignWithKey, "sign-with-key", "", "Path to a raw ed25519 private key to sign the published asset cache with. Requires --publish")
 
 	AddHelpFlag(cmd, "create-asset-cache")
 	return cmd
@@ -123,9 +161,114 @@ func validateAssetCacheFlags(flags *CreateAssetCacheFlags) error {
 	if flags.BuildpackLocator == "" {
 		return errors.New("must specify a buildpack locator using the --buildpack flag")
 	}
+	if flags.SignWithKey != "" && !flags.Publish {
+		return errors.New("must specify --publish to use --sign-with-key")
+	}
 	return nil
 }
 
+// assetCacheConfig is the schema of an asset-cache.toml, used to select a
+// subset of a buildpack's advertised assets when building a cache.
+type assetCacheConfig struct {
+	Assets  []assetCacheConfigAsset `toml:"assets"`
+	Include assetCacheConfigFilter  `toml:"include"`
+	Exclude assetCacheConfigFilter  `toml:"exclude"`
+}
+
+type assetCacheConfigAsset struct {
+	ID      string   `toml:"id"`
+	Version string   `toml:"version"`
+	Stacks  []string `toml:"stacks"`
+}
+
+type assetCacheConfigFilter struct {
+	ID    []string `toml:"id"`
+	Stack []string `toml:"stack"`
+}
+
+func readAssetCacheConfig(path string) (assetCacheConfig, error) {
+	var cfg assetCacheConfig
+	_, err := toml.DecodeFile(path, &cfg)
+	return cfg, err
+}
+
+// filterAssets subsets assets per cfg. When cfg declares explicit [[assets]]
+// entries, only those (id, version) pairs are kept, and it's an error for
+// one to be missing from assets. Otherwise, assets are filtered by the
+// include/exclude glob lists against asset ID and stack.
+func filterAssets(assets []dist.Asset, cfg assetCacheConfig) ([]dist.Asset, error) {
+	if len(cfg.Assets) > 0 {
+		return selectAssets(assets, cfg.Assets)
+	}
+
+	var result []dist.Asset
+	for _, asset := range assets {
+		if !matchesFilter(asset, cfg.Include, true) {
+			continue
+		}
+		if matchesFilter(asset, cfg.Exclude, false) {
+			continue
+		}
+		result = append(result, asset)
+	}
+	return result, nil
+}
+
+func selectAssets(assets []dist.Asset, wanted []assetCacheConfigAsset) ([]dist.Asset, error) {
+	byIDVersion := map[string]dist.Asset{}
+	for _, asset := range assets {
+		byIDVersion[asset.ID+"@"+asset.Version] = asset
+	}
+
+	var result []dist.Asset
+	for _, w := range wanted {
+		asset, ok := byIDVersion[w.ID+"@"+w.Version]
+		if !ok {
+			return nil, fmt.Errorf("required asset %s@%s not found in buildpack", w.ID, w.Version)
+		}
+		for _, stack := range w.Stacks {
+			if !containsString(asset.Stacks, stack) {
+				return nil, fmt.Errorf("required asset %s@%s does not support stack %q", w.ID, w.Version, stack)
+			}
+		}
+		result = append(result, asset)
+	}
+	return result, nil
+}
+
+func containsString(haystack []string, needle string) bool {
+	for _, s := range haystack {
+		if s == needle {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesFilter reports whether asset matches filter's glob patterns.
+// emptyResult is returned when filter declares no patterns at all, so an
+// empty include list matches everything and an empty exclude list excludes
+// nothing.
+func matchesFilter(asset dist.Asset, filter assetCacheConfigFilter, emptyResult bool) bool {
+	if len(filter.ID) == 0 && len(filter.Stack) == 0 {
+		return emptyResult
+	}
+
+	for _, pattern := range filter.ID {
+		if ok, _ := filepath.Match(pattern, asset.ID); ok {
+			return true
+		}
+	}
+	for _, pattern := range filter.Stack {
+		for _, stack := range asset.Stacks {
+			if ok, _ := filepath.Match(pattern, stack); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func getAssets(info *pack.BuildpackInfo) []dist.Asset {
 	result := []dist.Asset{}
 	assetMap := map[string]dist.Asset{}
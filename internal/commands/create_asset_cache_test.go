@@ -0,0 +1,125 @@
+package commands
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/buildpacks/pack/internal/dist"
+	h "github.com/buildpacks/pack/testhelpers"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestFilterAssets(t *testing.T) {
+	spec.Run(t, "filterAssets", testFilterAssets, spec.Parallel(), spec.Report(report.Terminal{}))
+}
+
+func TestReadAssetCacheConfig(t *testing.T) {
+	spec.Run(t, "readAssetCacheConfig", testReadAssetCacheConfig, spec.Parallel(), spec.Report(report.Terminal{}))
+}
+
+func testReadAssetCacheConfig(t *testing.T, when spec.G, it spec.S) {
+	var assert = h.NewAssertionManager(t)
+
+	when("given a real asset-cache.toml", func() {
+		it("decodes its assets, include, and exclude tables", func() {
+			tmpDir, err := ioutil.TempDir("", "asset-cache-config")
+			assert.Nil(err)
+			defer os.RemoveAll(tmpDir)
+
+			path := filepath.Join(tmpDir, "asset-cache.toml")
+			assert.Succeeds(ioutil.WriteFile(path, []byte(`
+[[assets]]
+id = "first-asset"
+version = "1.0.0"
+stacks = ["io.buildpacks.stacks.bionic"]
+
+[include]
+id = ["first-*", "second-*"]
+stack = ["io.buildpacks.stacks.bionic"]
+
+[exclude]
+id = ["second-*"]
+`), 0644))
+
+			cfg, err := readAssetCacheConfig(path)
+			assert.Nil(err)
+			assert.Equal(cfg, assetCacheConfig{
+				Assets: []assetCacheConfigAsset{
+					{ID: "first-asset", Version: "1.0.0", Stacks: []string{"io.buildpacks.stacks.bionic"}},
+				},
+				Include: assetCacheConfigFilter{
+					ID:    []string{"first-*", "second-*"},
+					Stack: []string{"io.buildpacks.stacks.bionic"},
+				},
+				Exclude: assetCacheConfigFilter{
+					ID: []string{"second-*"},
+				},
+			})
+		})
+	})
+}
+
+func testFilterAssets(t *testing.T, when spec.G, it spec.S) {
+	var (
+		assert = h.NewAssertionManager(t)
+		assets []dist.Asset
+	)
+
+	it.Before(func() {
+		assets = []dist.Asset{
+			{ID: "first-asset", Version: "1.0.0", Stacks: []string{"io.buildpacks.stacks.bionic"}},
+			{ID: "second-asset", Version: "2.0.0", Stacks: []string{"io.buildpacks.stacks.focal"}},
+		}
+	})
+
+	when("cfg declares explicit assets", func() {
+		it("keeps only the requested id@version pairs", func() {
+			result, err := filterAssets(assets, assetCacheConfig{
+				Assets: []assetCacheConfigAsset{{ID: "first-asset", Version: "1.0.0"}},
+			})
+			assert.Nil(err)
+			assert.Equal(result, []dist.Asset{assets[0]})
+		})
+
+		it("validates the requested stacks are supported", func() {
+			_, err := filterAssets(assets, assetCacheConfig{
+				Assets: []assetCacheConfigAsset{{ID: "first-asset", Version: "1.0.0", Stacks: []string{"io.buildpacks.stacks.focal"}}},
+			})
+			assert.ErrorContains(err, "does not support stack \"io.buildpacks.stacks.focal\"")
+		})
+
+		it("fails when a requested asset doesn't exist", func() {
+			_, err := filterAssets(assets, assetCacheConfig{
+				Assets: []assetCacheConfigAsset{{ID: "missing-asset", Version: "9.9.9"}},
+			})
+			assert.ErrorContains(err, "required asset missing-asset@9.9.9 not found in buildpack")
+		})
+	})
+
+	when("cfg declares include/exclude filters", func() {
+		it("keeps only assets matching an include glob", func() {
+			result, err := filterAssets(assets, assetCacheConfig{
+				Include: assetCacheConfigFilter{ID: []string{"first-*"}},
+			})
+			assert.Nil(err)
+			assert.Equal(result, []dist.Asset{assets[0]})
+		})
+
+		it("drops assets matching an exclude glob", func() {
+			result, err := filterAssets(assets, assetCacheConfig{
+				Exclude: assetCacheConfigFilter{ID: []string{"second-*"}},
+			})
+			assert.Nil(err)
+			assert.Equal(result, []dist.Asset{assets[0]})
+		})
+
+		it("keeps everything when no filters are set", func() {
+			result, err := filterAssets(assets, assetCacheConfig{})
+			assert.Nil(err)
+			assert.Equal(result, assets)
+		})
+	})
+}
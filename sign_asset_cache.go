@@ -0,0 +1,316 @@
+package pack
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/buildpacks/pack/asseterrors"
+	"github.com/buildpacks/pack/internal/dist"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/pkg/errors"
+)
+
+// cosignSignatureAnnotation is the OCI manifest annotation cosign records
+// a base64-encoded signature under.
+const cosignSignatureAnnotation = "dev.cosignproject.cosign/signature"
+
+// Signer produces a detached signature over an asset cache's digest and
+// attestation payload, in the cosign sense: the signature is stored
+// alongside the image under the sha256-<digest>.sig tag convention rather
+// than embedded in the image itself.
+type Signer interface {
+	Sign(ctx context.Context, digest v1.Hash, payload []byte) ([]byte, error)
+}
+
+// Verifier checks a detached signature produced by a Signer.
+type Verifier interface {
+	Verify(ctx context.Context, digest v1.Hash, payload, signature []byte) error
+}
+
+// FileKeySigner signs asset cache attestations with a raw, unencrypted
+// ed25519 private key loaded from disk. It's a minimal Signer for the
+// --sign-with-key CLI flag until pack grows a richer signing integration
+// (e.g. cosign's keyless flow). Its signature covers the attestation payload
+// only; the digest passed to Sign is not part of the signed bytes.
+type FileKeySigner struct {
+	privateKey ed25519.PrivateKey
+}
+
+// NewFileKeySigner reads a raw ed25519 private key from path.
+func NewFileKeySigner(path string) (*FileKeySigner, error) {
+	keyBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading asset cache signing key")
+	}
+	if len(keyBytes) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("invalid asset cache signing key %q: expected %d bytes, got %d", path, ed25519.PrivateKeySize, len(keyBytes))
+	}
+	return &FileKeySigner{privateKey: ed25519.PrivateKey(keyBytes)}, nil
+}
+
+// Sign implements Signer.
+func (s *FileKeySigner) Sign(ctx context.Context, digest v1.Hash, payload []byte) ([]byte, error) {
+	return ed25519.Sign(s.privateKey, payload), nil
+}
+
+// assetCacheAttestation records which assets an asset cache image claims
+// to contain, signed alongside the image so a consumer can refuse to
+// mount layers that don't match what was signed.
+type assetCacheAttestation struct {
+	Assets []assetCacheAttestationEntry `json:"assets"`
+}
+
+type assetCacheAttestationEntry struct {
+	ID      string `json:"id"`
+	Version string `json:"version"`
+	Sha256  string `json:"sha256"`
+	URI     string `json:"uri"`
+}
+
+func newAssetCacheAttestationPayload(assets []dist.Asset) []byte {
+	attestation := assetCacheAttestation{}
+	for _, asset := range assets {
+		attestation.Assets = append(attestation.Assets, assetCacheAttestationEntry{
+			ID:      asset.ID,
+			Version: asset.Version,
+			Sha256:  asset.Sha256,
+			URI:     asset.URI,
+		})
+	}
+	sort.Slice(attestation.Assets, func(i, j int) bool {
+		return attestation.Assets[i].Sha256 < attestation.Assets[j].Sha256
+	})
+
+	buf := bytes.NewBuffer(nil)
+	_ = json.NewEncoder(buf).Encode(attestation)
+	return buf.Bytes()
+}
+
+// signAssetCache signs digest's attestation payload with signer and pushes
+// the detached signature to ref's repository as an image tagged
+// sha256-<digest>.sig, per the cosign layout.
+func signAssetCache(ctx context.Context, ref name.Reference, digest v1.Hash, assets []dist.Asset, signer Signer) error {
+	payload := newAssetCacheAttestationPayload(assets)
+
+	sig, err := signer.Sign(ctx, digest, payload)
+	if err != nil {
+		return errors.Wrap(err, "signing asset cache image")
+	}
+
+	sigRef, err := sigTagFor(ref, digest)
+	if err != nil {
+		return err
+	}
+
+	payloadLayer, err := static.NewLayer(payload, types.MediaType("application/vnd.dev.cosign.simplesigning.v1+json"))
+	if err != nil {
+		return errors.Wrap(err, "building asset cache signature layer")
+	}
+
+	sigImg, err := mutate.Append(empty.Image, mutate.Addendum{
+		Layer: payloadLayer,
+		Annotations: map[string]string{
+			cosignSignatureAnnotation: base64.StdEncoding.EncodeToString(sig),
+		},
+	})
+	if err != nil {
+		return errors.Wrap(err, "building asset cache signature image")
+	}
+
+	if err := remote.Write(sigRef, sigImg, remote.WithAuthFromKeychain(authn.DefaultKeychain), remote.WithContext(ctx)); err != nil {
+		return errors.Wrap(err, "publishing asset cache signature")
+	}
+
+	return nil
+}
+
+// sigTagFor returns the cosign-convention signature tag for digest in
+// ref's repository, e.g. sha256-<hex>.sig.
+func sigTagFor(ref name.Reference, digest v1.Hash) (name.Tag, error) {
+	return name.NewTag(fmt.Sprintf("%s:%s-%s.sig", ref.Context().Name(), digest.Algorithm, digest.Hex))
+}
+
+// VerifyAssetCache fetches the asset cache artifact at ref along with its
+// cosign-style detached signature, verifies the signature over the
+// attestation payload with verifier, and confirms every asset recorded in
+// the artifact's AssetLayersLabel metadata is present in the signed
+// attestation. ref may name either a single-stack image or a multi-stack
+// OCI image index, in which case every child manifest's metadata is
+// checked. It's intended for use by `pack build` before mounting an asset
+// cache's layers into a build.
+func (c *Client) VerifyAssetCache(ctx context.Context, ref string, verifier Verifier) error {
+	imgRef, err := name.ParseReference(ref, name.WeakValidation)
+	if err != nil {
+		return errors.Wrap(err, "parsing asset cache reference")
+	}
+
+	remoteOpts := []remote.Option{
+		remote.WithAuthFromKeychain(authn.DefaultKeychain),
+		remote.WithContext(ctx),
+	}
+
+	desc, err := remote.Get(imgRef, remoteOpts...)
+	if err != nil {
+		return errors.Wrap(err, "fetching asset cache image")
+	}
+
+	assetShas, err := assetLayerShas(desc)
+	if err != nil {
+		return err
+	}
+
+	sigTag, err := sigTagFor(imgRef, desc.Digest)
+	if err != nil {
+		return err
+	}
+
+	sigImg, err := remote.Image(sigTag, remoteOpts...)
+	if err != nil {
+		return errors.Wrap(err, "fetching asset cache signature")
+	}
+
+	sigManifest, err := sigImg.Manifest()
+	if err != nil {
+		return errors.Wrap(err, "reading asset cache signature manifest")
+	}
+
+	encodedSig, ok := sigManifest.Annotations[cosignSignatureAnnotation]
+	if !ok {
+		return errors.New("asset cache image has no recorded signature")
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(encodedSig)
+	if err != nil {
+		return errors.Wrap(err, "decoding asset cache signature")
+	}
+
+	payload, err := signedAttestationPayload(sigImg)
+	if err != nil {
+		return errors.Wrap(err, "reading signed asset cache attestation")
+	}
+
+	if err := verifier.Verify(ctx, desc.Digest, payload, sig); err != nil {
+		return errors.Wrap(err, "verifying asset cache signature")
+	}
+
+	var attestation assetCacheAttestation
+	if err := json.Unmarshal(payload, &attestation); err != nil {
+		return errors.Wrap(err, "decoding asset cache attestation")
+	}
+
+	attested := map[string]bool{}
+	for _, entry := range attestation.Assets {
+		attested[entry.Sha256] = true
+	}
+
+	for sha := range assetShas {
+		if !attested[sha] {
+			return fmt.Errorf("%w: asset %q is not present in the signed attestation", asseterrors.ErrAssetNotFound, sha)
+		}
+	}
+
+	return nil
+}
+
+// assetLayerShas returns the set of asset sha256s recorded across desc's
+// AssetLayersLabel metadata. When desc is a multi-stack OCI image index
+// (see publishAssetCache), every child manifest's image is resolved and
+// its metadata included, since each child only carries the subset of
+// assets relevant to its own stack.
+func assetLayerShas(desc *remote.Descriptor) (map[string]bool, error) {
+	result := map[string]bool{}
+
+	if desc.MediaType.IsIndex() {
+		idx, err := desc.ImageIndex()
+		if err != nil {
+			return nil, errors.Wrap(err, "reading asset cache index")
+		}
+
+		manifest, err := idx.IndexManifest()
+		if err != nil {
+			return nil, errors.Wrap(err, "reading asset cache index manifest")
+		}
+
+		for _, childDesc := range manifest.Manifests {
+			childImg, err := idx.Image(childDesc.Digest)
+			if err != nil {
+				return nil, errors.Wrapf(err, "reading asset cache child image %s", childDesc.Digest)
+			}
+			if err := addAssetLayerShas(childImg, result); err != nil {
+				return nil, err
+			}
+		}
+
+		return result, nil
+	}
+
+	img, err := desc.Image()
+	if err != nil {
+		return nil, errors.Wrap(err, "reading asset cache image")
+	}
+	if err := addAssetLayerShas(img, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// addAssetLayerShas decodes img's AssetLayersLabel and adds every asset
+// sha256 it records into result.
+func addAssetLayerShas(img v1.Image, result map[string]bool) error {
+	cfg, err := img.ConfigFile()
+	if err != nil {
+		return errors.Wrap(err, "reading asset cache config")
+	}
+
+	var metadata AssetMetadata
+	if err := json.NewDecoder(strings.NewReader(cfg.Config.Labels[AssetLayersLabel])).Decode(&metadata); err != nil {
+		return errors.Wrap(err, "decoding asset cache metadata")
+	}
+
+	for sha := range metadata {
+		result[sha] = true
+	}
+	return nil
+}
+
+// signedAttestationPayload reads back the attestation payload stored as
+// sigImg's single layer by signAssetCache - the actual bytes that were
+// signed, as opposed to anything reconstructable from the target image
+// being verified.
+func signedAttestationPayload(sigImg v1.Image) ([]byte, error) {
+	layers, err := sigImg.Layers()
+	if err != nil {
+		return nil, err
+	}
+	if len(layers) != 1 {
+		return nil, fmt.Errorf("expected exactly one attestation layer, found %d", len(layers))
+	}
+
+	rc, err := layers[0].Uncompressed()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	buf := bytes.NewBuffer(nil)
+	if _, err := io.Copy(buf, rc); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
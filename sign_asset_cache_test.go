@@ -0,0 +1,217 @@
+package pack
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/buildpacks/pack/internal/dist"
+	h "github.com/buildpacks/pack/testhelpers"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestVerifyAssetCache(t *testing.T) {
+	spec.Run(t, "VerifyAssetCache", testVerifyAssetCache, spec.Parallel(), spec.Report(report.Terminal{}))
+}
+
+// ed25519Signer/ed25519Verifier are minimal Signer/Verifier implementations
+// used only to exercise the sign/verify round trip.
+type ed25519Signer struct{ key ed25519.PrivateKey }
+
+func (s ed25519Signer) Sign(ctx context.Context, digest v1.Hash, payload []byte) ([]byte, error) {
+	return ed25519.Sign(s.key, payload), nil
+}
+
+type ed25519Verifier struct{ key ed25519.PublicKey }
+
+func (v ed25519Verifier) Verify(ctx context.Context, digest v1.Hash, payload, signature []byte) error {
+	if !ed25519.Verify(v.key, payload, signature) {
+		return errors.New("signature verification failed")
+	}
+	return nil
+}
+
+func testVerifyAssetCache(t *testing.T, when spec.G, it spec.S) {
+	var (
+		assert = h.NewAssertionManager(t)
+		client *Client
+		server *httptest.Server
+		ref    string
+	)
+
+	it.Before(func() {
+		var err error
+		client, err = NewClient()
+		assert.Nil(err)
+
+		server = httptest.NewServer(registry.New())
+		ref = strings.TrimPrefix(server.URL, "http://") + "/test/asset-cache:latest"
+	})
+
+	it.After(func() {
+		server.Close()
+	})
+
+	when("the asset cache is signed and its metadata matches the attestation", func() {
+		it("verifies successfully", func() {
+			pub, priv, err := ed25519.GenerateKey(rand.Reader)
+			assert.Nil(err)
+
+			asset := dist.Asset{ID: "some-asset", Sha256: "some-sha256", Version: "1.0.0"}
+			pushAssetCacheImageForTest(t, ref, []dist.Asset{asset})
+
+			imgRef, err := name.ParseReference(ref, name.WeakValidation)
+			assert.Nil(err)
+			desc, err := remote.Get(imgRef)
+			assert.Nil(err)
+
+			assert.Succeeds(signAssetCache(context.Background(), imgRef, desc.Digest, []dist.Asset{asset}, ed25519Signer{priv}))
+
+			assert.Succeeds(client.VerifyAssetCache(context.Background(), ref, ed25519Verifier{pub}))
+		})
+	})
+
+	when("the cache's metadata claims an asset that isn't in the signed attestation", func() {
+		it("fails verification instead of trusting the unsigned metadata", func() {
+			pub, priv, err := ed25519.GenerateKey(rand.Reader)
+			assert.Nil(err)
+
+			attested := dist.Asset{ID: "attested-asset", Sha256: "attested-sha256", Version: "1.0.0"}
+			pushAssetCacheImageForTest(t, ref, []dist.Asset{attested})
+
+			imgRef, err := name.ParseReference(ref, name.WeakValidation)
+			assert.Nil(err)
+			desc, err := remote.Get(imgRef)
+			assert.Nil(err)
+			assert.Succeeds(signAssetCache(context.Background(), imgRef, desc.Digest, []dist.Asset{attested}, ed25519Signer{priv}))
+
+			// Tamper with the published image's metadata to claim an
+			// additional, unsigned-for asset, and carry the original
+			// attested-only signature over to the tampered digest. If
+			// VerifyAssetCache rebuilt its expected attestation from this
+			// same (tampered) metadata instead of the signed payload, this
+			// would pass when it shouldn't.
+			tampered := dist.Asset{ID: "unattested-asset", Sha256: "unattested-sha256", Version: "1.0.0"}
+			pushAssetCacheImageForTest(t, ref, []dist.Asset{attested, tampered})
+
+			tamperedDesc, err := remote.Get(imgRef)
+			assert.Nil(err)
+			assert.Succeeds(signAssetCache(context.Background(), imgRef, tamperedDesc.Digest, []dist.Asset{attested}, ed25519Signer{priv}))
+
+			err = client.VerifyAssetCache(context.Background(), ref, ed25519Verifier{pub})
+			assert.ErrorContains(err, "unattested-sha256")
+			assert.ErrorContains(err, "not present in the signed attestation")
+		})
+	})
+
+	when("the asset cache is a multi-stack OCI image index", func() {
+		it("verifies every child manifest's metadata against the attestation", func() {
+			pub, priv, err := ed25519.GenerateKey(rand.Reader)
+			assert.Nil(err)
+
+			bionic := dist.Asset{ID: "bionic-asset", Sha256: "bionic-sha256", Version: "1.0.0"}
+			jammy := dist.Asset{ID: "jammy-asset", Sha256: "jammy-sha256", Version: "1.0.0"}
+			digest := pushMultiStackAssetCacheIndexForTest(t, ref, map[string][]dist.Asset{
+				"bionic": {bionic},
+				"jammy":  {jammy},
+			})
+
+			imgRef, err := name.ParseReference(ref, name.WeakValidation)
+			assert.Nil(err)
+
+			assert.Succeeds(signAssetCache(context.Background(), imgRef, digest, []dist.Asset{bionic, jammy}, ed25519Signer{priv}))
+
+			assert.Succeeds(client.VerifyAssetCache(context.Background(), ref, ed25519Verifier{pub}))
+		})
+	})
+}
+
+// pushAssetCacheImageForTest pushes a minimal image labeled with assets'
+// AssetMetadata to ref, mimicking what buildAssetCacheImage produces.
+func pushAssetCacheImageForTest(t *testing.T, ref string, assets []dist.Asset) {
+	t.Helper()
+	assert := h.NewAssertionManager(t)
+
+	metadata := AssetMetadata{}
+	for _, asset := range assets {
+		metadata[asset.Sha256] = asset
+	}
+
+	labelBytes, err := json.Marshal(metadata)
+	assert.Nil(err)
+
+	img := empty.Image
+	cfg, err := img.ConfigFile()
+	assert.Nil(err)
+	cfg = cfg.DeepCopy()
+	if cfg.Config.Labels == nil {
+		cfg.Config.Labels = map[string]string{}
+	}
+	cfg.Config.Labels[AssetLayersLabel] = string(labelBytes)
+
+	img, err = mutate.ConfigFile(img, cfg)
+	assert.Nil(err)
+
+	imgRef, err := name.ParseReference(ref, name.WeakValidation)
+	assert.Nil(err)
+	assert.Succeeds(remote.Write(imgRef, img))
+}
+
+// pushMultiStackAssetCacheIndexForTest builds one minimal image per stack in
+// assetsByStack, each labeled with its own AssetMetadata, and pushes them to
+// ref as a single OCI image index, mimicking the multi-stack branch of
+// publishAssetCache. It returns the index's digest, for signing.
+func pushMultiStackAssetCacheIndexForTest(t *testing.T, ref string, assetsByStack map[string][]dist.Asset) v1.Hash {
+	t.Helper()
+	assert := h.NewAssertionManager(t)
+
+	var adds []mutate.IndexAddendum
+	for _, stack := range sortedStackKeys(assetsByStack) {
+		metadata := AssetMetadata{}
+		for _, asset := range assetsByStack[stack] {
+			metadata[asset.Sha256] = asset
+		}
+
+		labelBytes, err := json.Marshal(metadata)
+		assert.Nil(err)
+
+		img := empty.Image
+		cfg, err := img.ConfigFile()
+		assert.Nil(err)
+		cfg = cfg.DeepCopy()
+		if cfg.Config.Labels == nil {
+			cfg.Config.Labels = map[string]string{}
+		}
+		cfg.Config.Labels[AssetLayersLabel] = string(labelBytes)
+
+		img, err = mutate.ConfigFile(img, cfg)
+		assert.Nil(err)
+
+		adds = append(adds, mutate.IndexAddendum{
+			Add:        img,
+			Descriptor: v1.Descriptor{Platform: stackPlatform(stack)},
+		})
+	}
+
+	idx := mutate.AppendManifests(empty.Index, adds...)
+
+	imgRef, err := name.ParseReference(ref, name.WeakValidation)
+	assert.Nil(err)
+	assert.Succeeds(remote.WriteIndex(imgRef, idx))
+
+	digest, err := idx.Digest()
+	assert.Nil(err)
+	return digest
+}